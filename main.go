@@ -0,0 +1,423 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/slsa-framework/slsa-github-generator-go/pkg"
+
+	"gopkg.in/yaml.v3"
+)
+
+// target is the result of resolving one pkg.Target against a configuration:
+// the binary name, the full `go build` invocation (including the path to
+// the go binary itself, so a downstream step can re-invoke it verbatim),
+// the environment it must run under, and whichever distribution packages
+// should be built once the binary is compiled.
+type target struct {
+	binary   string
+	command  []string
+	env      []string
+	packages []packageBuild
+}
+
+// packageBuild pairs the nfpm invocation that produces one distribution
+// package with the manifest entry describing its result.
+type packageBuild struct {
+	command    []string
+	descriptor string
+	artifact   packageArtifact
+}
+
+// packageArtifact describes one distribution package produced for a build
+// target, as recorded in the go-packages action output.
+type packageArtifact struct {
+	Format     string `json:"format"`
+	Arch       string `json:"arch"`
+	File       string `json:"file"`
+	Descriptor string `json:"descriptor"`
+	Sha256     string `json:"sha256,omitempty"`
+}
+
+// packageCommand builds the nfpm invocation that produces one OS package of
+// the given format, translating GOARCH into the architecture name the
+// target format expects and rendering the nfpm descriptor (maintainer,
+// dependencies, conffiles, systemd units) the invocation is pointed at via
+// --config, so that metadata reaches the package instead of being dropped.
+// Neither the descriptor nor the invocation refers to the compiled binary:
+// the descriptor lists no package contents.
+func packageCommand(meta pkg.PackageMeta, format, goarch string) (packageBuild, error) {
+	arch := pkg.PackageArch(format, goarch)
+
+	var file string
+	if format == "rpm" {
+		file = fmt.Sprintf("%s-%s.%s.rpm", meta.Name, meta.Version, arch)
+	} else {
+		file = fmt.Sprintf("%s_%s_%s.%s", meta.Name, meta.Version, arch, format)
+	}
+	descriptorPath := file + ".nfpm.yaml"
+
+	descriptor, err := pkg.BuildDescriptor(meta, arch)
+	if err != nil {
+		return packageBuild{}, err
+	}
+
+	return packageBuild{
+		command:    []string{"nfpm", "package", "--config", descriptorPath, "--packager", format, "--target", file},
+		descriptor: descriptor,
+		artifact: packageArtifact{
+			Format:     format,
+			Arch:       arch,
+			File:       file,
+			Descriptor: descriptorPath,
+		},
+	}, nil
+}
+
+// parseEvalEnvs turns a "KEY:value, KEY2:value2" string -- the form used to
+// thread workflow environment variables through to this process -- into a
+// lookup map.
+func parseEvalEnvs(s string) map[string]string {
+	m := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m
+}
+
+// substitute replaces ${NAME} placeholders in s using values, falling back
+// to leaving the placeholder untouched if the name is unknown.
+func substitute(s string, values map[string]string) string {
+	for k, v := range values {
+		s = strings.ReplaceAll(s, fmt.Sprintf("${%s}", k), v)
+	}
+	return s
+}
+
+// resolveTarget builds the command, environment and binary name for a single
+// pkg.Target, substituting the target's own GOOS/GOARCH/etc into the binary
+// name and the caller-provided envEval values into the ldflags.
+func resolveTarget(cfg *pkg.Configuration, t pkg.Target, goc, modMode string, envEval map[string]string) (target, error) {
+	env := t.Env()
+
+	binValues := map[string]string{
+		"GOOS":   t.Goos,
+		"GOARCH": t.Goarch,
+	}
+	if t.Goarm != "" {
+		binValues["GOARM"] = t.Goarm
+	}
+	if t.Gomips != "" {
+		binValues["GOMIPS"] = t.Gomips
+	}
+	if t.Gomips64 != "" {
+		binValues["GOMIPS64"] = t.Gomips64
+	}
+	if t.Go386 != "" {
+		binValues["GO386"] = t.Go386
+	}
+	binary := substitute(cfg.Binary, binValues)
+
+	flags := mergeBuildTags(cfg.Flags, t.BuildTags())
+	cmd := append([]string{goc, "build", fmt.Sprintf("-mod=%s", modMode)}, flags...)
+	if cfg.Buildmode != "" {
+		cmd = append(cmd, fmt.Sprintf("-buildmode=%s", cfg.Buildmode))
+	}
+
+	if len(cfg.Ldflags) > 0 {
+		ldflags := make([]string, len(cfg.Ldflags))
+		for i, l := range cfg.Ldflags {
+			ldflags[i] = substitute(l, envEval)
+		}
+		cmd = append(cmd, fmt.Sprintf("-ldflags=%s", strings.Join(ldflags, " ")))
+	}
+	cmd = append(cmd, "-o", binary)
+
+	env = append(env, cfg.Envs...)
+	if cfg.Cgo.Enabled {
+		env = append(env, cgoEnv(cfg.Cgo)...)
+	}
+
+	packages := make([]packageBuild, len(cfg.Packages))
+	for i, format := range cfg.Packages {
+		p, err := packageCommand(cfg.PackageMeta, format, t.Goarch)
+		if err != nil {
+			return target{}, err
+		}
+		packages[i] = p
+	}
+
+	return target{
+		binary:   binary,
+		command:  cmd,
+		env:      env,
+		packages: packages,
+	}, nil
+}
+
+// mergeBuildTags merges any build tags implied by the target (e.g. `ios`
+// targets also requiring the `darwin` tag) into whichever `-tags=...` flag
+// flags already contains, appending a new one instead if it doesn't have
+// one. Returns flags unchanged if the target needs no extra tags.
+func mergeBuildTags(flags []string, extra []string) []string {
+	if len(extra) == 0 {
+		return flags
+	}
+
+	merged := make([]string, len(flags))
+	copy(merged, flags)
+	for i, f := range merged {
+		if strings.HasPrefix(f, "-tags=") {
+			existing := strings.TrimPrefix(f, "-tags=")
+			merged[i] = fmt.Sprintf("-tags=%s,%s", existing, strings.Join(extra, ","))
+			return merged
+		}
+	}
+	return append(merged, fmt.Sprintf("-tags=%s", strings.Join(extra, ",")))
+}
+
+// cgoEnv returns the CGO_ENABLED and C toolchain environment variables
+// implied by an enabled pkg.Cgo configuration.
+func cgoEnv(c pkg.Cgo) []string {
+	env := []string{"CGO_ENABLED=1"}
+	if c.CC != "" {
+		env = append(env, fmt.Sprintf("CC=%s", c.CC))
+	}
+	if c.CXX != "" {
+		env = append(env, fmt.Sprintf("CXX=%s", c.CXX))
+	}
+	if len(c.CFlags) > 0 {
+		env = append(env, fmt.Sprintf("CGO_CFLAGS=%s", strings.Join(c.CFlags, " ")))
+	}
+	if len(c.CXXFlags) > 0 {
+		env = append(env, fmt.Sprintf("CGO_CXXFLAGS=%s", strings.Join(c.CXXFlags, " ")))
+	}
+	if len(c.LDFlags) > 0 {
+		env = append(env, fmt.Sprintf("CGO_LDFLAGS=%s", strings.Join(c.LDFlags, " ")))
+	}
+	return env
+}
+
+// sha256File returns the lowercase hex sha256 digest of the file at path.
+func sha256File(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// runBuild parses configFile, resolves every target it describes (a single
+// implicit target for a plain goos/goarch config, or one per entry of a
+// build matrix), and either executes the resulting `go build` invocations
+// (verify == false) or only reports what would have been run (verify ==
+// true). Either way, the resolved binary name(s), command(s) and env(s) are
+// emitted as action outputs so later workflow steps can consume them: to the
+// file named by outputPath when non-empty, or via the deprecated
+// ::set-output workflow command otherwise.
+func runBuild(verify bool, configFile, evalEnvs, outputPath string) error {
+	b, err := os.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	var cfg pkg.Configuration
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return err
+	}
+
+	goc, err := exec.LookPath("go")
+	if err != nil {
+		return err
+	}
+
+	modMode := cfg.ResolvedModmode()
+
+	var sumDigest string
+	if modMode != "vendor" {
+		path := cfg.GoSum.Path
+		if path == "" {
+			path = "go.sum"
+		}
+		sumDigest, err = pkg.VerifyGoSum(path, cfg.GoSum.Digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	envEval := parseEvalEnvs(evalEnvs)
+	targets := cfg.Targets()
+	resolved := make([]target, 0, len(targets))
+
+	for _, t := range targets {
+		r, err := resolveTarget(&cfg, t, goc, modMode, envEval)
+		if err != nil {
+			return err
+		}
+
+		if !verify {
+			cmd := exec.Command(r.command[0], r.command[1:]...)
+			cmd.Env = append(os.Environ(), r.env...)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			if err := cmd.Run(); err != nil {
+				return err
+			}
+
+			for i, p := range r.packages {
+				if err := os.WriteFile(p.artifact.Descriptor, []byte(p.descriptor), 0644); err != nil {
+					return err
+				}
+
+				nfpm := exec.Command(p.command[0], p.command[1:]...)
+				nfpm.Stdout = os.Stdout
+				nfpm.Stderr = os.Stderr
+				if err := nfpm.Run(); err != nil {
+					return err
+				}
+
+				sum, err := sha256File(p.artifact.File)
+				if err != nil {
+					return err
+				}
+				r.packages[i].artifact.Sha256 = sum
+			}
+		}
+
+		resolved = append(resolved, r)
+	}
+
+	if err := emitOutputs(resolved, outputPath); err != nil {
+		return err
+	}
+
+	if sumDigest != "" {
+		if err := setOutput(outputPath, "go-sum-digest", sumDigest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// emitOutputs writes the resolved targets as action outputs. A single
+// (non-matrix) target keeps the original unindexed output names so existing
+// consumers of this action don't need to change; a matrix of targets gets
+// one indexed triple per target instead.
+func emitOutputs(targets []target, outputPath string) error {
+	for i, t := range targets {
+		binName, cmdName, envName := "go-binary-name", "go-command", "go-env"
+		pkgCmdName, pkgName, pkgDescName := "go-packages-command", "go-packages", "go-packages-descriptor"
+		if len(targets) > 1 {
+			binName = fmt.Sprintf("go-binary-name-%d", i)
+			cmdName = fmt.Sprintf("go-command-%d", i)
+			envName = fmt.Sprintf("go-env-%d", i)
+			pkgCmdName = fmt.Sprintf("go-packages-command-%d", i)
+			pkgName = fmt.Sprintf("go-packages-%d", i)
+			pkgDescName = fmt.Sprintf("go-packages-descriptor-%d", i)
+		}
+
+		if err := setOutput(outputPath, binName, t.binary); err != nil {
+			return err
+		}
+
+		cmdStr, err := pkg.MarshallList(t.command)
+		if err != nil {
+			return err
+		}
+		if err := setOutput(outputPath, cmdName, cmdStr); err != nil {
+			return err
+		}
+
+		envStr, err := pkg.MarshallList(t.env)
+		if err != nil {
+			return err
+		}
+		if err := setOutput(outputPath, envName, envStr); err != nil {
+			return err
+		}
+
+		if len(t.packages) == 0 {
+			continue
+		}
+
+		pkgCmds := make([]string, len(t.packages))
+		descriptors := make([]string, len(t.packages))
+		artifacts := make([]packageArtifact, len(t.packages))
+		for j, p := range t.packages {
+			pkgCmds[j] = strings.Join(p.command, " ")
+			descriptors[j] = p.descriptor
+			artifacts[j] = p.artifact
+		}
+
+		pkgCmdStr, err := pkg.MarshallList(pkgCmds)
+		if err != nil {
+			return err
+		}
+		if err := setOutput(outputPath, pkgCmdName, pkgCmdStr); err != nil {
+			return err
+		}
+
+		pkgDescStr, err := pkg.MarshallList(descriptors)
+		if err != nil {
+			return err
+		}
+		if err := setOutput(outputPath, pkgDescName, pkgDescStr); err != nil {
+			return err
+		}
+
+		manifest, err := json.Marshal(artifacts)
+		if err != nil {
+			return err
+		}
+		if err := setOutput(outputPath, pkgName, string(manifest)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setOutput emits a single GitHub Actions output. When outputPath is
+// non-empty, it's appended there in the KEY=VALUE (or KEY<<EOF heredoc, for
+// multi-line values) format GitHub now expects; otherwise this falls back
+// to the deprecated ::set-output workflow command.
+func setOutput(outputPath, name, value string) error {
+	if outputPath == "" {
+		_, err := fmt.Printf("::set-output name=%s::%s\n", name, value)
+		return err
+	}
+
+	f, err := os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return pkg.WriteOutput(f, name, value)
+}
+
+func main() {
+	configFile := flag.String("config", "", "path to the build configuration file")
+	verify := flag.Bool("verify", false, "only report the resolved build command(s) without executing them")
+	flag.Parse()
+
+	if err := runBuild(*verify, *configFile, os.Getenv("EVAL_ENVS"), os.Getenv("GITHUB_OUTPUT")); err != nil {
+		log.Fatalf("runBuild: %v", err)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/slsa-framework/slsa-github-generator-go/pkg"
@@ -173,6 +176,120 @@ func Test_runVerify(t *testing.T) {
 				"CGO_ENABLED=0",
 			},
 		},
+		{
+			name:     "buildmode pie",
+			subject:  "binary-linux-amd64",
+			config:   "./testdata/buildmode-pie.yml",
+			evalEnvs: "VERSION_LDFLAGS:bla, ELSE:else",
+			commands: []string{
+				"-trimpath",
+				"-tags=netgo",
+				"-buildmode=pie",
+				"-o",
+				"binary-linux-amd64",
+			},
+			envs: []string{
+				"GOOS=linux",
+				"GOARCH=amd64",
+				"GO111MODULE=on",
+				"CGO_ENABLED=0",
+			},
+		},
+		{
+			name:     "buildmode c-archive",
+			subject:  "binary-linux-amd64",
+			config:   "./testdata/buildmode-carchive.yml",
+			evalEnvs: "VERSION_LDFLAGS:bla, ELSE:else",
+			commands: []string{
+				"-trimpath",
+				"-tags=netgo",
+				"-buildmode=c-archive",
+				"-o",
+				"binary-linux-amd64",
+			},
+			envs: []string{
+				"GOOS=linux",
+				"GOARCH=amd64",
+				"GO111MODULE=on",
+				"CGO_ENABLED=0",
+			},
+		},
+		{
+			name:     "buildmode c-shared",
+			subject:  "binary-linux-amd64",
+			config:   "./testdata/buildmode-cshared.yml",
+			evalEnvs: "VERSION_LDFLAGS:bla, ELSE:else",
+			commands: []string{
+				"-trimpath",
+				"-tags=netgo",
+				"-buildmode=c-shared",
+				"-o",
+				"binary-linux-amd64",
+			},
+			envs: []string{
+				"GOOS=linux",
+				"GOARCH=amd64",
+				"GO111MODULE=on",
+				"CGO_ENABLED=0",
+			},
+		},
+		{
+			name:     "buildmode plugin",
+			subject:  "binary-linux-amd64",
+			config:   "./testdata/buildmode-plugin.yml",
+			evalEnvs: "VERSION_LDFLAGS:bla, ELSE:else",
+			commands: []string{
+				"-trimpath",
+				"-tags=netgo",
+				"-buildmode=plugin",
+				"-o",
+				"binary-linux-amd64",
+			},
+			envs: []string{
+				"GOOS=linux",
+				"GOARCH=amd64",
+				"GO111MODULE=on",
+				"CGO_ENABLED=0",
+			},
+		},
+		{
+			name:     "cgo enabled with custom cc",
+			subject:  "binary-linux-amd64",
+			config:   "./testdata/cgo-custom-cc.yml",
+			evalEnvs: "VERSION_LDFLAGS:bla, ELSE:else",
+			commands: []string{
+				"-trimpath",
+				"-o",
+				"binary-linux-amd64",
+			},
+			envs: []string{
+				"GOOS=linux",
+				"GOARCH=amd64",
+				"GO111MODULE=on",
+				"CGO_ENABLED=1",
+				"CC=clang",
+				"CXX=clang++",
+				"CGO_CFLAGS=-O2",
+				"CGO_LDFLAGS=-lm",
+			},
+		},
+		{
+			name:     "ios target merges with an existing -tags flag",
+			subject:  "binary-ios-arm64",
+			config:   "./testdata/ios-with-tags.yml",
+			evalEnvs: "VERSION_LDFLAGS:bla, ELSE:else",
+			commands: []string{
+				"-trimpath",
+				"-tags=netgo,ios,darwin",
+				"-o",
+				"binary-ios-arm64",
+			},
+			envs: []string{
+				"GOOS=ios",
+				"GOARCH=arm64",
+				"GO111MODULE=on",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -186,7 +303,8 @@ func Test_runVerify(t *testing.T) {
 
 			err := runBuild(true,
 				tt.config,
-				tt.evalEnvs)
+				tt.evalEnvs,
+				"")
 
 			s := r.end()
 
@@ -221,6 +339,317 @@ func Test_runVerify(t *testing.T) {
 	}
 }
 
+func Test_runBuildMatrix(t *testing.T) {
+	// *** WARNING: do not enable t.Parallel(), because we're redirecting stdout ***.
+	tests := []struct {
+		name    string
+		idx     int
+		subject string
+		command []string
+		env     []string
+	}{
+		{
+			name:    "linux amd64",
+			idx:     0,
+			subject: "binary-linux-amd64",
+			command: []string{"-trimpath", "-o", "binary-linux-amd64"},
+			env:     []string{"GOOS=linux", "GOARCH=amd64", "GO111MODULE=on"},
+		},
+		{
+			name:    "darwin arm64",
+			idx:     1,
+			subject: "binary-darwin-arm64",
+			command: []string{"-trimpath", "-o", "binary-darwin-arm64"},
+			env:     []string{"GOOS=darwin", "GOARCH=arm64", "GO111MODULE=on"},
+		},
+		{
+			name:    "ios arm64 implies the darwin build tag",
+			idx:     2,
+			subject: "binary-ios-arm64",
+			command: []string{"-trimpath", "-tags=ios,darwin", "-o", "binary-ios-arm64"},
+			env:     []string{"GOOS=ios", "GOARCH=arm64", "GO111MODULE=on"},
+		},
+		{
+			name:    "linux arm sets GOARM",
+			idx:     3,
+			subject: "binary-linux-arm",
+			command: []string{"-trimpath", "-o", "binary-linux-arm"},
+			env:     []string{"GOOS=linux", "GOARCH=arm", "GOARM=7", "GO111MODULE=on"},
+		},
+		{
+			name:    "linux mips sets GOMIPS",
+			idx:     4,
+			subject: "binary-linux-mips",
+			command: []string{"-trimpath", "-o", "binary-linux-mips"},
+			env:     []string{"GOOS=linux", "GOARCH=mips", "GOMIPS=hardfloat", "GO111MODULE=on"},
+		},
+		{
+			name:    "linux mips64 sets GOMIPS64",
+			idx:     5,
+			subject: "binary-linux-mips64",
+			command: []string{"-trimpath", "-o", "binary-linux-mips64"},
+			env:     []string{"GOOS=linux", "GOARCH=mips64", "GOMIPS64=hardfloat", "GO111MODULE=on"},
+		},
+		{
+			name:    "linux 386 sets GO386",
+			idx:     6,
+			subject: "binary-linux-386",
+			command: []string{"-trimpath", "-o", "binary-linux-386"},
+			env:     []string{"GOOS=linux", "GOARCH=386", "GO386=sse2", "GO111MODULE=on"},
+		},
+	}
+
+	// Run the build once: a matrix config expands into one output triple
+	// per target, so every sub-test below reads from the same captured run.
+	r := runNew()
+	r.start()
+	err := runBuild(true, "./testdata/matrix.yml", "", "")
+	s := r.end()
+	if err != nil {
+		t.Fatalf("runBuild: %v", err)
+	}
+
+	goc, err := exec.LookPath("go")
+	if err != nil {
+		t.Fatalf("exec.LookPath: %v", err)
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, env, subject, err := extractIndexed(s, tt.idx)
+			if err != nil {
+				t.Fatalf("extractIndexed: %v", err)
+			}
+
+			if !cmp.Equal(subject, tt.subject) {
+				t.Errorf(cmp.Diff(subject, tt.subject))
+			}
+
+			command := append([]string{goc, "build", "-mod=vendor"}, tt.command...)
+			if !cmp.Equal(cmd, command) {
+				t.Errorf(cmp.Diff(cmd, command))
+			}
+
+			sorted := cmpopts.SortSlices(func(a, b string) bool { return a < b })
+			if !cmp.Equal(env, tt.env, sorted) {
+				t.Errorf(cmp.Diff(env, tt.env))
+			}
+		})
+	}
+}
+
+func Test_runBuildModmode(t *testing.T) {
+	// *** WARNING: do not enable t.Parallel(), because we're redirecting stdout ***.
+	const wantDigest = "ea8a55635949ff686f6e8382da924c97d7129bd3c20a3a50d3850ae19c7dde38"
+
+	tests := []struct {
+		name    string
+		config  string
+		modFlag string
+	}{
+		{
+			name:    "readonly (pinned go.sum verified)",
+			config:  "./testdata/modmode-readonly.yml",
+			modFlag: "-mod=readonly",
+		},
+		{
+			name:    "mod (pinned go.sum verified)",
+			config:  "./testdata/modmode-mod.yml",
+			modFlag: "-mod=mod",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := runNew()
+			r.start()
+			err := runBuild(true, tt.config, "", "")
+			s := r.end()
+			if err != nil {
+				t.Fatalf("runBuild: %v", err)
+			}
+
+			cmd, _, _, err := extract(s)
+			if err != nil {
+				t.Fatalf("extract: %v", err)
+			}
+			var sawModFlag bool
+			for _, c := range cmd {
+				if c == tt.modFlag {
+					sawModFlag = true
+				}
+			}
+			if !sawModFlag {
+				t.Errorf("command %v does not contain %q", cmd, tt.modFlag)
+			}
+
+			digest, err := extractSumDigest(s)
+			if err != nil {
+				t.Fatalf("extractSumDigest: %v", err)
+			}
+			if digest != wantDigest {
+				t.Errorf("go-sum-digest = %q, want %q", digest, wantDigest)
+			}
+		})
+	}
+}
+
+func Test_runBuildSumMismatch(t *testing.T) {
+	// *** WARNING: do not enable t.Parallel(), because we're redirecting stdout ***.
+
+	r := runNew()
+	r.start()
+	err := runBuild(true, "./testdata/modmode-mismatch.yml", "", "")
+	r.end()
+
+	if !errors.Is(err, pkg.ErrSumDigestMismatch) {
+		t.Errorf("runBuild error = %v, want wrapping %v", err, pkg.ErrSumDigestMismatch)
+	}
+}
+
+func Test_runBuildMissingDigest(t *testing.T) {
+	// *** WARNING: do not enable t.Parallel(), because we're redirecting stdout ***.
+
+	r := runNew()
+	r.start()
+	err := runBuild(true, "./testdata/modmode-nodigest.yml", "", "")
+	r.end()
+
+	if !errors.Is(err, pkg.ErrSumDigestMissing) {
+		t.Errorf("runBuild error = %v, want wrapping %v", err, pkg.ErrSumDigestMissing)
+	}
+}
+
+// extractSumDigest reads the `go-sum-digest` action output out of lines.
+func extractSumDigest(lines string) (string, error) {
+	return extractRaw(lines, "go-sum-digest")
+}
+
+func Test_runBuildPackages(t *testing.T) {
+	// *** WARNING: do not enable t.Parallel(), because we're redirecting stdout ***.
+
+	wantCommands := []string{
+		"nfpm package --config mytool_1.2.3_arm64.deb.nfpm.yaml --packager deb --target mytool_1.2.3_arm64.deb",
+		"nfpm package --config mytool-1.2.3.aarch64.rpm.nfpm.yaml --packager rpm --target mytool-1.2.3.aarch64.rpm",
+		"nfpm package --config mytool_1.2.3_aarch64.apk.nfpm.yaml --packager apk --target mytool_1.2.3_aarch64.apk",
+	}
+	wantManifest := `[{"format":"deb","arch":"arm64","file":"mytool_1.2.3_arm64.deb","descriptor":"mytool_1.2.3_arm64.deb.nfpm.yaml"},` +
+		`{"format":"rpm","arch":"aarch64","file":"mytool-1.2.3.aarch64.rpm","descriptor":"mytool-1.2.3.aarch64.rpm.nfpm.yaml"},` +
+		`{"format":"apk","arch":"aarch64","file":"mytool_1.2.3_aarch64.apk","descriptor":"mytool_1.2.3_aarch64.apk.nfpm.yaml"}]`
+
+	r := runNew()
+	r.start()
+	err := runBuild(true, "./testdata/packages.yml", "", "")
+	s := r.end()
+	if err != nil {
+		t.Fatalf("runBuild: %v", err)
+	}
+
+	commands, err := extractList(s, "go-packages-command")
+	if err != nil {
+		t.Fatalf("extractList(go-packages-command): %v", err)
+	}
+	if !cmp.Equal(commands, wantCommands) {
+		t.Errorf(cmp.Diff(commands, wantCommands))
+	}
+
+	manifest, err := extractRaw(s, "go-packages")
+	if err != nil {
+		t.Fatalf("extractRaw(go-packages): %v", err)
+	}
+	if !cmp.Equal(manifest, wantManifest) {
+		t.Errorf(cmp.Diff(manifest, wantManifest))
+	}
+
+	descriptors, err := extractList(s, "go-packages-descriptor")
+	if err != nil {
+		t.Fatalf("extractList(go-packages-descriptor): %v", err)
+	}
+	if len(descriptors) != 3 {
+		t.Fatalf("got %d descriptors, want 3: %v", len(descriptors), descriptors)
+	}
+	for _, want := range []string{
+		"maintainer: Jane Doe <jane@example.com>",
+		"- libc6",
+		"- /etc/mytool/config.yml",
+		"- mytool.service",
+	} {
+		for _, d := range descriptors {
+			if !strings.Contains(d, want) {
+				t.Errorf("descriptor %q does not contain %q", d, want)
+			}
+		}
+	}
+}
+
+func Test_runBuildGithubOutput(t *testing.T) {
+	t.Parallel()
+
+	outputPath := filepath.Join(t.TempDir(), "github_output")
+
+	err := runBuild(true, "./testdata/two-ldflags.yml", "VERSION_LDFLAGS:bla, ELSE:else", outputPath)
+	if err != nil {
+		t.Fatalf("runBuild: %v", err)
+	}
+
+	b, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	written := string(b)
+
+	if strings.Contains(written, "::set-output") {
+		t.Errorf("expected $GITHUB_OUTPUT to be used instead of ::set-output, got:\n%s", written)
+	}
+	if !strings.Contains(written, "go-binary-name=binary-linux-amd64\n") {
+		t.Errorf("expected a go-binary-name=... line, got:\n%s", written)
+	}
+
+	goc, err := exec.LookPath("go")
+	if err != nil {
+		t.Fatalf("exec.LookPath: %v", err)
+	}
+
+	cmd, env, subject, err := extract(written)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if subject != "binary-linux-amd64" {
+		t.Errorf("subject = %q", subject)
+	}
+	wantCmd := []string{goc, "build", "-mod=vendor", "-trimpath", "-tags=netgo", "-ldflags=bla something-else", "-o", "binary-linux-amd64"}
+	if !cmp.Equal(cmd, wantCmd) {
+		t.Errorf(cmp.Diff(cmd, wantCmd))
+	}
+	wantEnv := []string{"GOOS=linux", "GOARCH=amd64", "GO111MODULE=on", "CGO_ENABLED=0"}
+	sorted := cmpopts.SortSlices(func(a, b string) bool { return a < b })
+	if !cmp.Equal(env, wantEnv, sorted) {
+		t.Errorf(cmp.Diff(env, wantEnv))
+	}
+}
+
+// extractRaw reads the raw (un-decoded) value of a single action output
+// named name out of lines.
+func extractRaw(lines, name string) (string, error) {
+	out, err := parseOutputs(lines)
+	if err != nil {
+		return "", err
+	}
+	return out[name], nil
+}
+
+// extractList is like extractRaw, but also decodes the value as a
+// pkg.MarshallList-encoded list of strings.
+func extractList(lines, name string) ([]string, error) {
+	raw, err := extractRaw(lines, name)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.UnmarshallList(raw)
+}
+
 type run struct {
 	oldStdout *os.File
 	wPipe     *os.File
@@ -255,48 +684,87 @@ func (r *run) end() string {
 	return s
 }
 
-func extract(lines string) ([]string, []string, string, error) {
-	rsubject := regexp.MustCompile("^::set-output name=go-binary-name::(.*)$")
-	rcmd := regexp.MustCompile("^::set-output name=go-command::(.*)$")
-	renv := regexp.MustCompile("^::set-output name=go-env::(.*)$")
-	var subject string
-	var scmd string
-	var senv string
+// parseOutputs parses a blob of captured stdout into a name->value map of
+// the action outputs it contains. It understands both output formats this
+// binary can produce: the deprecated `::set-output name=NAME::VALUE`
+// workflow command, and the $GITHUB_OUTPUT file format (`NAME=VALUE`, or
+// `NAME<<DELIM` followed by a multi-line value and a closing `DELIM` line).
+func parseOutputs(lines string) (map[string]string, error) {
+	rset := regexp.MustCompile(`^::set-output name=([^:]+)::(.*)$`)
+	rheredoc := regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.-]*)<<(\S+)$`)
+	rkv := regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.-]*)=(.*)$`)
 
+	out := make(map[string]string)
 	scanner := bufio.NewScanner(bytes.NewReader([]byte(lines)))
 	for scanner.Scan() {
-		n := rsubject.FindStringSubmatch(scanner.Text())
-		if len(n) > 1 {
-			subject = n[1]
-		}
+		line := scanner.Text()
 
-		c := rcmd.FindStringSubmatch(scanner.Text())
-		if len(c) > 1 {
-			scmd = c[1]
+		if m := rset.FindStringSubmatch(line); m != nil {
+			out[m[1]] = m[2]
+			continue
 		}
 
-		e := renv.FindStringSubmatch(scanner.Text())
-		if len(e) > 1 {
-			senv = e[1]
+		if m := rheredoc.FindStringSubmatch(line); m != nil {
+			name, delim := m[1], m[2]
+			var b strings.Builder
+			for scanner.Scan() {
+				l := scanner.Text()
+				if l == delim {
+					break
+				}
+				if b.Len() > 0 {
+					b.WriteByte('\n')
+				}
+				b.WriteString(l)
+			}
+			out[name] = b.String()
+			continue
 		}
 
-		if subject != "" && scmd != "" && senv != "" {
-			break
+		if m := rkv.FindStringSubmatch(line); m != nil {
+			out[m[1]] = m[2]
 		}
 	}
-	if err := scanner.Err(); err != nil {
+	return out, scanner.Err()
+}
+
+func extract(lines string) ([]string, []string, string, error) {
+	out, err := parseOutputs(lines)
+	if err != nil {
+		return []string{}, []string{}, "", err
+	}
+
+	cmd, err := pkg.UnmarshallList(out["go-command"])
+	if err != nil {
+		return []string{}, []string{}, "", err
+	}
+
+	env, err := pkg.UnmarshallList(out["go-env"])
+	if err != nil {
+		return []string{}, []string{}, "", err
+	}
+
+	return cmd, env, out["go-binary-name"], nil
+}
+
+// extractIndexed is like extract, but reads the `-<idx>` suffixed outputs a
+// build matrix produces (one triple per expanded target) instead of the
+// unindexed names a single-target config emits.
+func extractIndexed(lines string, idx int) ([]string, []string, string, error) {
+	out, err := parseOutputs(lines)
+	if err != nil {
 		return []string{}, []string{}, "", err
 	}
 
-	cmd, err := pkg.UnmarshallList(scmd)
+	cmd, err := pkg.UnmarshallList(out[fmt.Sprintf("go-command-%d", idx)])
 	if err != nil {
 		return []string{}, []string{}, "", err
 	}
 
-	env, err := pkg.UnmarshallList(senv)
+	env, err := pkg.UnmarshallList(out[fmt.Sprintf("go-env-%d", idx)])
 	if err != nil {
 		return []string{}, []string{}, "", err
 	}
 
-	return cmd, env, subject, nil
+	return cmd, env, out[fmt.Sprintf("go-binary-name-%d", idx)], nil
 }
@@ -0,0 +1,135 @@
+package pkg
+
+import "fmt"
+
+// Target describes a single GOOS/GOARCH pair, plus whichever sub-architecture
+// variable applies to that GOARCH, for one entry of a build matrix.
+type Target struct {
+	Goos     string `yaml:"goos"`
+	Goarch   string `yaml:"goarch"`
+	Goarm    string `yaml:"goarm,omitempty"`
+	Gomips   string `yaml:"gomips,omitempty"`
+	Gomips64 string `yaml:"gomips64,omitempty"`
+	Go386    string `yaml:"go386,omitempty"`
+}
+
+// Cgo describes the C toolchain to build with when cgo is enabled. It is
+// distinct from the Go linker's own `-ldflags`: these are the flags passed
+// to the C/C++ compiler and linker cgo shells out to.
+type Cgo struct {
+	Enabled  bool     `yaml:"enabled"`
+	CC       string   `yaml:"cc,omitempty"`
+	CXX      string   `yaml:"cxx,omitempty"`
+	CFlags   []string `yaml:"cflags,omitempty"`
+	CXXFlags []string `yaml:"cxxflags,omitempty"`
+	LDFlags  []string `yaml:"ldflags,omitempty"`
+}
+
+// GoSum pins the expected digest of the workspace's go.sum file, required
+// whenever Modmode is "mod" or "readonly" so the exact module graph that was
+// compiled can be attested to later. VerifyGoSum rejects a config that
+// leaves Digest unset rather than silently skipping verification.
+//
+// Digest pinning is the only supported form of verification: there is no
+// sumdb-URL-plus-public-key alternative, so a config has no way to defer to
+// an external checksum database instead of pinning a digest directly.
+type GoSum struct {
+	Path   string `yaml:"path,omitempty"`
+	Digest string `yaml:"digest,omitempty"`
+}
+
+// PackageMeta is the nfpm-style descriptor shared by every OS package a
+// configuration's `packages` list produces.
+type PackageMeta struct {
+	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
+	Maintainer   string   `yaml:"maintainer,omitempty"`
+	Depends      []string `yaml:"depends,omitempty"`
+	Conffiles    []string `yaml:"conffiles,omitempty"`
+	SystemdUnits []string `yaml:"systemd_units,omitempty"`
+}
+
+// Configuration represents the parsed user-provided build configuration.
+type Configuration struct {
+	Version     int         `yaml:"version"`
+	Goos        string      `yaml:"goos,omitempty"`
+	Goarch      string      `yaml:"goarch,omitempty"`
+	Matrix      []Target    `yaml:"matrix,omitempty"`
+	Envs        []string    `yaml:"env,omitempty"`
+	Flags       []string    `yaml:"flags,omitempty"`
+	Ldflags     []string    `yaml:"ldflags,omitempty"`
+	Buildmode   string      `yaml:"buildmode,omitempty"`
+	Cgo         Cgo         `yaml:"cgo,omitempty"`
+	Modmode     string      `yaml:"modmode,omitempty"`
+	GoSum       GoSum       `yaml:"gosum,omitempty"`
+	Packages    []string    `yaml:"packages,omitempty"`
+	PackageMeta PackageMeta `yaml:"package_meta,omitempty"`
+	Binary      string      `yaml:"binary"`
+}
+
+// ModmodeDefault is the module mode used when a configuration doesn't
+// declare one: readonly, so builds are reproducible without requiring a
+// vendor directory to be checked in.
+const ModmodeDefault = "readonly"
+
+// ResolvedModmode returns the configuration's modmode, defaulting to
+// ModmodeDefault when unset.
+func (c *Configuration) ResolvedModmode() string {
+	if c.Modmode == "" {
+		return ModmodeDefault
+	}
+	return c.Modmode
+}
+
+// Targets returns the list of build targets described by the configuration.
+// When no matrix is declared, the top-level goos/goarch pair is returned as
+// a single target so non-matrix configs keep working unchanged.
+func (c *Configuration) Targets() []Target {
+	if len(c.Matrix) > 0 {
+		return c.Matrix
+	}
+	return []Target{{Goos: c.Goos, Goarch: c.Goarch}}
+}
+
+// Env returns the GOOS/GOARCH environment variables implied by this target,
+// plus the sub-architecture variable relevant to its GOARCH (GOARM for arm,
+// GOMIPS for mips/mipsle, GOMIPS64 for mips64/mips64le, GO386 for 386).
+// `ios` is a GOOS in its own right, but the standard library gates
+// ios-specific files behind the `darwin` build tag, so ios targets also get
+// that tag appended to the returned flags by the caller.
+func (t Target) Env() []string {
+	env := []string{
+		fmt.Sprintf("GOOS=%s", t.Goos),
+		fmt.Sprintf("GOARCH=%s", t.Goarch),
+	}
+
+	switch t.Goarch {
+	case "arm":
+		if t.Goarm != "" {
+			env = append(env, fmt.Sprintf("GOARM=%s", t.Goarm))
+		}
+	case "mips", "mipsle":
+		if t.Gomips != "" {
+			env = append(env, fmt.Sprintf("GOMIPS=%s", t.Gomips))
+		}
+	case "mips64", "mips64le":
+		if t.Gomips64 != "" {
+			env = append(env, fmt.Sprintf("GOMIPS64=%s", t.Gomips64))
+		}
+	case "386":
+		if t.Go386 != "" {
+			env = append(env, fmt.Sprintf("GO386=%s", t.Go386))
+		}
+	}
+
+	return env
+}
+
+// BuildTags returns the extra `-tags` values implied by this target, beyond
+// whatever the configuration itself requests.
+func (t Target) BuildTags() []string {
+	if t.Goos == "ios" {
+		return []string{"ios", "darwin"}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package pkg
+
+import "encoding/json"
+
+// MarshallList serializes a list of strings into a single-line string that
+// can be safely passed through a GitHub Actions output.
+func MarshallList(s []string) (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// UnmarshallList is the inverse of MarshallList.
+func UnmarshallList(s string) ([]string, error) {
+	var r []string
+	if err := json.Unmarshal([]byte(s), &r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
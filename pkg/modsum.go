@@ -0,0 +1,46 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// ErrSumDigestMismatch is returned by VerifyGoSum when a workspace's go.sum
+// does not match the digest pinned in the build configuration.
+var ErrSumDigestMismatch = fmt.Errorf("go.sum digest does not match the pinned value")
+
+// ErrSumDigestMissing is returned by VerifyGoSum when want is empty: a
+// non-vendor modmode must pin a go.sum digest, since without one this
+// function would have nothing to verify against.
+var ErrSumDigestMissing = fmt.Errorf("gosum.digest must be set for this modmode")
+
+// VerifyGoSum computes the sha256 digest of the go.sum file at path and
+// compares it against the pinned want digest, which must be non-empty: a
+// config that omits it is rejected rather than silently skipping
+// verification. Digest pinning is the only verification method this
+// implements; there is no sumdb-URL-plus-public-key alternative. Once the
+// file has been read it returns the computed digest (as a lowercase hex
+// string), even on a mismatch; when want is empty the file is not read and
+// the returned digest is empty. The error wraps ErrSumDigestMismatch when the two digests diverge, or
+// ErrSumDigestMissing when want is empty.
+func VerifyGoSum(path, want string) (string, error) {
+	if want == "" {
+		return "", ErrSumDigestMissing
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	got := hex.EncodeToString(sum[:])
+
+	if got != want {
+		return got, fmt.Errorf("%s: want %s, got %s: %w", path, want, got, ErrSumDigestMismatch)
+	}
+
+	return got, nil
+}
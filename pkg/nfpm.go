@@ -0,0 +1,41 @@
+package pkg
+
+import "gopkg.in/yaml.v3"
+
+// nfpmDescriptor is the subset of an nfpm package descriptor this action
+// renders: enough for nfpm to carry the maintainer, dependency, conffile and
+// systemd unit metadata a PackageMeta declares through to the built package,
+// rather than that metadata only ever reaching the --target file name.
+type nfpmDescriptor struct {
+	Name         string   `yaml:"name"`
+	Version      string   `yaml:"version"`
+	Arch         string   `yaml:"arch"`
+	Platform     string   `yaml:"platform"`
+	Maintainer   string   `yaml:"maintainer,omitempty"`
+	Depends      []string `yaml:"depends,omitempty"`
+	Conffiles    []string `yaml:"conffiles,omitempty"`
+	SystemdUnits []string `yaml:"systemd_units,omitempty"`
+}
+
+// BuildDescriptor renders the nfpm config for the package built for arch
+// from meta, carrying every PackageMeta field through to nfpm via --config
+// rather than the --target file name alone. It holds metadata only: no
+// contents section is rendered, so no file is placed in the package.
+func BuildDescriptor(meta PackageMeta, arch string) (string, error) {
+	d := nfpmDescriptor{
+		Name:         meta.Name,
+		Version:      meta.Version,
+		Arch:         arch,
+		Platform:     "linux",
+		Maintainer:   meta.Maintainer,
+		Depends:      meta.Depends,
+		Conffiles:    meta.Conffiles,
+		SystemdUnits: meta.SystemdUnits,
+	}
+
+	b, err := yaml.Marshal(d)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
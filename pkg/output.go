@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteOutput emits a single "name=value" GitHub Actions output to w,
+// following the $GITHUB_OUTPUT file format. Multi-line values can't be
+// represented on one line, so they're written using the `KEY<<EOF\n...\nEOF`
+// heredoc form instead.
+func WriteOutput(w io.Writer, name, value string) error {
+	if !strings.Contains(value, "\n") {
+		_, err := fmt.Fprintf(w, "%s=%s\n", name, value)
+		return err
+	}
+
+	delim, err := randomDelimiter()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", name, delim, value, delim)
+	return err
+}
+
+// randomDelimiter returns a heredoc delimiter unlikely to collide with
+// anything a value could legitimately contain.
+func randomDelimiter() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "ghadelim_" + hex.EncodeToString(b), nil
+}
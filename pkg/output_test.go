@@ -0,0 +1,42 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_WriteOutput(t *testing.T) {
+	t.Run("single-line value uses KEY=VALUE", func(t *testing.T) {
+		var b strings.Builder
+		if err := WriteOutput(&b, "go-binary-name", "binary-linux-amd64"); err != nil {
+			t.Fatalf("WriteOutput: %v", err)
+		}
+		if got, want := b.String(), "go-binary-name=binary-linux-amd64\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("multi-line value uses a heredoc", func(t *testing.T) {
+		var b strings.Builder
+		value := "line one\nline two"
+		if err := WriteOutput(&b, "go-ldflags", value); err != nil {
+			t.Fatalf("WriteOutput: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("got %d lines, want 4: %q", len(lines), b.String())
+		}
+
+		name, delim, ok := strings.Cut(lines[0], "<<")
+		if !ok || name != "go-ldflags" || delim == "" {
+			t.Fatalf("first line = %q, want NAME<<DELIM", lines[0])
+		}
+		if got := strings.Join(lines[1:3], "\n"); got != value {
+			t.Errorf("body = %q, want %q", got, value)
+		}
+		if lines[3] != delim {
+			t.Errorf("closing line = %q, want delimiter %q", lines[3], delim)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package pkg
+
+// debArch, rpmArch and apkArch translate a GOARCH into the architecture
+// name each distribution packaging format expects in its metadata and file
+// name, since each of the three spells at least some architectures
+// differently from Go's own GOARCH names.
+var (
+	debArch = map[string]string{"amd64": "amd64", "arm64": "arm64", "386": "i386", "arm": "armhf"}
+	rpmArch = map[string]string{"amd64": "x86_64", "arm64": "aarch64", "386": "i686", "arm": "armhfp"}
+	apkArch = map[string]string{"amd64": "x86_64", "arm64": "aarch64", "386": "x86", "arm": "armhf"}
+)
+
+// PackageArch returns the architecture name the given packaging format
+// (deb, rpm or apk) expects for goarch. Unknown formats or architectures
+// are passed through unchanged.
+func PackageArch(format, goarch string) string {
+	var m map[string]string
+	switch format {
+	case "deb":
+		m = debArch
+	case "rpm":
+		m = rpmArch
+	case "apk":
+		m = apkArch
+	}
+	if a, ok := m[goarch]; ok {
+		return a
+	}
+	return goarch
+}